@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+var unicodePieces = map[chess.PieceType]map[chess.Color]string{
+	chess.King:   {chess.White: "♔", chess.Black: "♚"},
+	chess.Queen:  {chess.White: "♕", chess.Black: "♛"},
+	chess.Rook:   {chess.White: "♖", chess.Black: "♜"},
+	chess.Bishop: {chess.White: "♗", chess.Black: "♝"},
+	chess.Knight: {chess.White: "♘", chess.Black: "♞"},
+	chess.Pawn:   {chess.White: "♙", chess.Black: "♟"},
+}
+
+var startingCounts = map[chess.PieceType]int{
+	chess.Pawn:   8,
+	chess.Knight: 2,
+	chess.Bishop: 2,
+	chess.Rook:   2,
+	chess.Queen:  1,
+}
+
+const highlightOn = "\x1b[43m"
+const highlightOff = "\x1b[0m"
+
+// renderBoard draws game's current position as a unicode board, highlighting
+// the squares of the last move and showing side to move, clocks and
+// captured material.
+func renderBoard(game *chess.Game, wtimeMs int, btimeMs int) string {
+	var b strings.Builder
+
+	board := game.Position().Board()
+
+	var lastFrom, lastTo chess.Square
+	moves := game.Moves()
+	hasLastMove := len(moves) > 0
+	if hasLastMove {
+		last := moves[len(moves)-1]
+		lastFrom, lastTo = last.S1(), last.S2()
+	}
+
+	for rank := 7; rank >= 0; rank-- {
+		fmt.Fprintf(&b, "%d ", rank+1)
+		for file := 0; file < 8; file++ {
+			sq := chess.NewSquare(chess.File(file), chess.Rank(rank))
+			piece := board.Piece(sq)
+
+			cell := "."
+			if piece != chess.NoPiece {
+				cell = unicodePieces[piece.Type()][piece.Color()]
+			}
+
+			if hasLastMove && (sq == lastFrom || sq == lastTo) {
+				fmt.Fprintf(&b, "%s%s%s ", highlightOn, cell, highlightOff)
+			} else {
+				fmt.Fprintf(&b, "%s ", cell)
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("  a b c d e f g h\n")
+
+	sideToMove := "White"
+	if game.Position().Turn() == chess.Black {
+		sideToMove = "Black"
+	}
+	fmt.Fprintf(&b, "%s to move | White %s  Black %s\n", sideToMove, formatClock(wtimeMs), formatClock(btimeMs))
+
+	if captured := capturedMaterial(board); captured != "" {
+		b.WriteString(captured)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func formatClock(ms int) string {
+	if ms <= 0 {
+		return "--:--"
+	}
+
+	d := time.Duration(ms) * time.Millisecond
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// capturedMaterial lists, for each side, which of the standard starting
+// pieces are no longer on the board.
+func capturedMaterial(board *chess.Board) string {
+	onBoard := map[chess.Color]map[chess.PieceType]int{
+		chess.White: {},
+		chess.Black: {},
+	}
+
+	for _, piece := range board.SquareMap() {
+		onBoard[piece.Color()][piece.Type()]++
+	}
+
+	white := missingPieces(onBoard[chess.White])
+	black := missingPieces(onBoard[chess.Black])
+
+	if white == "" && black == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("Captured - White lost: %s | Black lost: %s", white, black)
+}
+
+func missingPieces(onBoard map[chess.PieceType]int) string {
+	// A promoted pawn inflates onBoard[promotedType] past its starting count
+	// without ever being captured; count those promotions so the pawn they
+	// came from isn't reported as captured material.
+	promotions := 0
+	for pieceType, total := range startingCounts {
+		if pieceType == chess.Pawn {
+			continue
+		}
+		if extra := onBoard[pieceType] - total; extra > 0 {
+			promotions += extra
+		}
+	}
+
+	var captured []string
+	for pieceType, total := range startingCounts {
+		missing := total - onBoard[pieceType]
+		if pieceType == chess.Pawn {
+			missing -= promotions
+		}
+		for i := 0; i < missing; i++ {
+			captured = append(captured, unicodePieces[pieceType][chess.White])
+		}
+	}
+
+	return strings.Join(captured, " ")
+}
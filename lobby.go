@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type lobbyEvent struct {
+	Type string
+	Game struct {
+		Id string
+	}
+}
+
+type nowPlayingResponse struct {
+	NowPlaying []struct {
+		GameId string `json:"gameId"`
+	} `json:"nowPlaying"`
+}
+
+// seek issues a public (or rated) seek via the board API and blocks until the
+// seek is matched, returning the id of the resulting game.
+func seek(s settings, timeControl string, rated bool) (string, error) {
+	minutes, increment, err := parseTimeControl(timeControl)
+	if err != nil {
+		return "", err
+	}
+
+	before, err := activeGameIds(s)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("time", minutes)
+	form.Set("increment", increment)
+	form.Set("rated", strconv.FormatBool(rated))
+
+	req, err := http.NewRequest("POST", "https://lichess.org/api/board/seek", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("Couldn't create the seek")
+	}
+
+	// The seek response body stays open until the seek is matched or
+	// cancelled, so it must be closed once we're done waiting.
+	defer resp.Body.Close()
+
+	return waitForGameStart(s, before)
+}
+
+// challenge sends a direct challenge to user via the board API and blocks
+// until it is accepted, returning the id of the resulting game.
+func challenge(s settings, user string) (string, error) {
+	before, err := activeGameIds(s)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("https://lichess.org/api/challenge/%s", user)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("Couldn't challenge %s", user)
+	}
+	resp.Body.Close()
+
+	return waitForGameStart(s, before)
+}
+
+// activeGameIds returns the ids of the games the user is already playing, so
+// waitForGameStart can tell a gameStart event for a brand new game apart from
+// one for an unrelated game that was already in progress.
+func activeGameIds(s settings) (map[string]bool, error) {
+	req, err := http.NewRequest("GET", "https://lichess.org/api/account/playing", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Couldn't list the user's active games")
+	}
+
+	var parsed nowPlayingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+
+	ids := map[string]bool{}
+	for _, g := range parsed.NowPlaying {
+		ids[g.GameId] = true
+	}
+	return ids, nil
+}
+
+// waitForGameStart listens on /api/stream/event until a gameStart event for a
+// game not in before (the games already active when the seek/challenge was
+// issued) arrives, and returns its game id.
+func waitForGameStart(s settings, before map[string]bool) (string, error) {
+	req, err := http.NewRequest("GET", "https://lichess.org/api/stream/event", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("Couldn't start listening for events")
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var e lobbyEvent
+		if err := dec.Decode(&e); err != nil {
+			return "", err
+		}
+
+		if e.Type == "gameStart" && !before[e.Game.Id] {
+			return e.Game.Id, nil
+		}
+	}
+
+	return "", fmt.Errorf("event stream closed before a game started")
+}
+
+// parseTimeControl turns a "minutes+increment" string (e.g. "5+3") into the
+// form values expected by the seek endpoint.
+func parseTimeControl(timeControl string) (minutes string, increment string, err error) {
+	m, i, found := strings.Cut(timeControl, "+")
+	if !found || m == "" || i == "" {
+		return "", "", fmt.Errorf("invalid time control %q, expected format like 5+3", timeControl)
+	}
+
+	return m, i, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
@@ -18,6 +19,14 @@ type gameState struct {
 	State struct {
 		Moves  string
 		Status string
+
+		WDraw     bool
+		BDraw     bool
+		WTakeback bool
+		BTakeback bool
+
+		Wtime int
+		Btime int
 	}
 
 	White struct {
@@ -27,7 +36,20 @@ type gameState struct {
 		Id string
 	}
 
-	Moves string
+	Moves  string
+	Status string
+
+	WDraw     bool
+	BDraw     bool
+	WTakeback bool
+	BTakeback bool
+
+	Wtime int
+	Btime int
+
+	// Populated on "chatLine" events.
+	Username string
+	Text     string
 }
 
 type settings struct {
@@ -101,6 +123,70 @@ func resign(s settings) error {
 	return nil
 }
 
+func draw(s settings, accept bool) error {
+	return postGameCommand(s, fmt.Sprintf("draw/%s", yesNo(accept)))
+}
+
+func takeback(s settings, accept bool) error {
+	return postGameCommand(s, fmt.Sprintf("takeback/%s", yesNo(accept)))
+}
+
+func abort(s settings) error {
+	return postGameCommand(s, "abort")
+}
+
+func chat(s settings, text string) error {
+	endpoint := fmt.Sprintf("https://lichess.org/api/board/game/%s/chat", s.GameId)
+
+	form := url.Values{}
+	form.Set("room", "player")
+	form.Set("text", text)
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Couldn't send the chat message")
+	}
+
+	return nil
+}
+
+func postGameCommand(s settings, command string) error {
+	url := fmt.Sprintf("https://lichess.org/api/board/game/%s/%s", s.GameId, command)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Couldn't send the %s command", command)
+	}
+
+	return nil
+}
+
+func yesNo(accept bool) string {
+	if accept {
+		return "yes"
+	}
+	return "no"
+}
+
 func doLichessRequest(s settings, req *http.Request) (*http.Response, error) {
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
 
@@ -110,17 +196,51 @@ func doLichessRequest(s settings, req *http.Request) (*http.Response, error) {
 func main() {
 	var s settings
 
-	flag.StringVar(&s.Token, "token", "", "lichess auth token (with board:play scope)")
-	flag.StringVar(&s.GameId, "gameid", "", "lichess game id")
-	flag.StringVar(&s.User, "user", "", "lichess user id")
-	flag.Parse()
+	args := os.Args[1:]
+	var subcommand string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand = args[0]
+		args = args[1:]
+	}
 
-	if s.Token == "" {
-		log.Fatal("Missing lichess auth token")
+	var timeControl string
+	var rated bool
+	var opponent string
+
+	var resume bool
+	var stateDir string
+	var pgnDir string
+
+	var enginePath string
+	var auto bool
+	var movetimeMs int
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.StringVar(&s.Token, "token", "", "lichess auth token (with board:play scope)")
+	fs.StringVar(&s.GameId, "gameid", "", "lichess game id")
+	fs.StringVar(&s.User, "user", "", "lichess user id")
+	fs.BoolVar(&resume, "resume", false, "resume a game from the on-disk PGN after a restart")
+	fs.StringVar(&stateDir, "statedir", "games", "directory used to persist in-progress games for --resume")
+	fs.StringVar(&pgnDir, "pgn-dir", "pgn", "directory finished games are archived to, and the `save` command writes to")
+	fs.StringVar(&enginePath, "engine", "stockfish", "path to a UCI engine binary, used by hint/eval/--auto")
+	fs.BoolVar(&auto, "auto", false, "automatically play the engine's move after every opponent move")
+	fs.IntVar(&movetimeMs, "movetime", 1000, "milliseconds the engine is given to think per move")
+
+	switch subcommand {
+	case "seek":
+		fs.StringVar(&timeControl, "time", "5+3", "time control as minutes+increment, e.g. 5+3")
+		fs.BoolVar(&rated, "rated", false, "create a rated seek")
+	case "challenge":
+		if len(args) == 0 {
+			log.Fatal("Missing opponent user id, usage: playlichess-cli challenge <user>")
+		}
+		opponent = args[0]
+		args = args[1:]
 	}
+	fs.Parse(args)
 
-	if s.GameId == "" {
-		log.Fatal("Missing lichess game id")
+	if s.Token == "" {
+		log.Fatal("Missing lichess auth token")
 	}
 
 	if s.User == "" {
@@ -130,20 +250,68 @@ func main() {
 	s.User = strings.ToLower(s.User)
 	s.Client = &http.Client{}
 
+	switch subcommand {
+	case "seek":
+		gameId, err := seek(s, timeControl, rated)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.GameId = gameId
+	case "challenge":
+		gameId, err := challenge(s, opponent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.GameId = gameId
+	}
+
+	if subcommand == "multi" {
+		playMulti(s)
+		return
+	}
+
+	if s.GameId == "" {
+		log.Fatal("Missing lichess game id")
+	}
+
+	engine := &engineHolder{path: enginePath, movetimeMs: movetimeMs}
+	defer engine.Close()
+
+	playGame(s, resume, stateDir, pgnDir, engine, auto)
+}
+
+func playGame(s settings, resume bool, stateDir string, pgnDir string, engine *engineHolder, auto bool) {
 	resp, err := startStream(s)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer resp.Body.Close()
-
 	playingWithWhite := true
 	localGame := chess.NewGame(chess.UseNotation(algebraicNotation))
 	remoteGame := chess.NewGame(chess.UseNotation(longAlgebraicNotation))
 
+	var resumedGame *chess.Game
+	if resume {
+		if restored, ok, err := loadPGN(stateDir, s.GameId, algebraicNotation); err != nil {
+			log.Fatal(err)
+		} else if ok {
+			resumedGame = restored
+			fmt.Printf("Resumed game %s from %s\n", s.GameId, pgnPath(stateDir, s.GameId))
+		}
+	}
+
 	completer := func(d prompt.Document) []prompt.Suggest {
 		s := []prompt.Suggest{
 			{Text: "resign", Description: "resign the game"},
+			{Text: "draw yes", Description: "offer or accept a draw"},
+			{Text: "draw no", Description: "decline a draw offer"},
+			{Text: "takeback yes", Description: "offer or accept a takeback"},
+			{Text: "takeback no", Description: "decline a takeback offer"},
+			{Text: "abort", Description: "abort the game"},
+			{Text: "chat", Description: "chat <message> sends a message to the opponent"},
+			{Text: "save", Description: "save the game's PGN to --pgn-dir"},
+			{Text: "hint", Description: "ask the engine for its top move"},
+			{Text: "eval", Description: "ask the engine for its evaluation of the position"},
 			{Text: "exit", Description: "exit the cli"},
 		}
 		for _, m := range localGame.ValidMoves() {
@@ -153,13 +321,21 @@ func main() {
 		return prompt.FilterHasPrefix(s, d.GetWordBeforeCursor(), true)
 	}
 
+	finished := false
 	dec := json.NewDecoder(resp.Body)
 
-	for dec.More() {
+	for !finished {
 		var state gameState
 		err := dec.Decode(&state)
 		if err != nil {
-			log.Fatal(err)
+			resp.Body.Close()
+
+			resp, err = reconnectStream(s)
+			if err != nil {
+				log.Fatal(err)
+			}
+			dec = json.NewDecoder(resp.Body)
+			continue
 		}
 
 		if state.Type == "gameFull" {
@@ -167,6 +343,8 @@ func main() {
 				log.Fatal("game is not active")
 			}
 
+			remoteGame = chess.NewGame(chess.UseNotation(longAlgebraicNotation))
+
 			moves := strings.Split(state.State.Moves, " ")
 			for _, m := range moves {
 				if err := remoteGame.MoveStr(m); err != nil {
@@ -174,8 +352,24 @@ func main() {
 				}
 			}
 
-			for _, m := range remoteGame.Moves() {
-				localGame.Move(m)
+			if resumedGame != nil {
+				localGame = resumedGame
+				resumedGame = nil
+			} else {
+				localGame = chess.NewGame(chess.UseNotation(algebraicNotation))
+			}
+
+			// Fast-forward localGame to remoteGame's moves the same way the
+			// gameState delta sync below does, instead of always rebuilding
+			// it from scratch: this is what actually lets --resume carry a
+			// locally-sent move across a restart that lichess hasn't yet
+			// reflected back on the very first gameFull.
+			for i := len(localGame.Moves()); i < len(remoteGame.Moves()); i++ {
+				localGame.Move(remoteGame.Moves()[i])
+			}
+
+			if err := savePGN(stateDir, s.GameId, localGame); err != nil {
+				log.Println("Couldn't persist the game PGN:", err)
 			}
 
 			if state.White.Id == s.User {
@@ -186,30 +380,15 @@ func main() {
 				log.Fatal("user does not match with any of the sides on the game state from lichess")
 			}
 
+			printOffers(playingWithWhite, state.State.WDraw, state.State.BDraw, state.State.WTakeback, state.State.BTakeback)
+			fmt.Print(renderBoard(localGame, state.State.Wtime, state.State.Btime))
+
 			isWhiteTurn := len(moves)%2 == 0
 			if (playingWithWhite && isWhiteTurn) || (!playingWithWhite && !isWhiteTurn) {
-				for {
-					t := prompt.Input("> ", completer)
-
-					switch t {
-					case "exit":
-						os.Exit(0)
-					case "resign":
-						resign(s)
-						os.Exit(0)
-					}
-
-					err := localGame.MoveStr(t)
-
-					if err != nil {
-						fmt.Println("Invalid move", err)
-					} else {
-						err := sendMove(s, localGame.Position(), localGame.Moves()[len(localGame.Moves())-1])
-						if err != nil {
-							log.Fatal(err)
-						}
-						break
-					}
+				if auto {
+					playEngineMove(s, localGame, engine, stateDir, state.State.Wtime, state.State.Btime)
+				} else {
+					takeTurn(s, localGame, completer, stateDir, pgnDir, engine, state.State.Wtime, state.State.Btime)
 				}
 			}
 		} else if state.Type == "gameState" {
@@ -225,33 +404,177 @@ func main() {
 				}
 			}
 
+			if err := savePGN(stateDir, s.GameId, localGame); err != nil {
+				log.Println("Couldn't persist the game PGN:", err)
+			}
+
+			printOffers(playingWithWhite, state.WDraw, state.BDraw, state.WTakeback, state.BTakeback)
+			fmt.Print(renderBoard(localGame, state.Wtime, state.Btime))
+
+			if state.Status != "started" {
+				archiveGame(pgnDir, s.GameId, localGame)
+				finished = true
+				continue
+			}
+
 			isWhiteTurn := len(moves)%2 == 0
 			if (playingWithWhite && isWhiteTurn) || (!playingWithWhite && !isWhiteTurn) {
-				for {
-					t := prompt.Input("> ", completer)
-
-					switch t {
-					case "exit":
-						os.Exit(0)
-					case "resign":
-						resign(s)
-						os.Exit(0)
-					}
-
-					err := localGame.MoveStr(t)
-
-					if err != nil {
-						fmt.Println("Invalid move", err)
-					} else {
-						err := sendMove(s, localGame.Position(), localGame.Moves()[len(localGame.Moves())-1])
-						if err != nil {
-							log.Fatal(err)
-						}
-						break
-					}
+				if auto {
+					playEngineMove(s, localGame, engine, stateDir, state.Wtime, state.Btime)
+				} else {
+					takeTurn(s, localGame, completer, stateDir, pgnDir, engine, state.Wtime, state.Btime)
 				}
 			}
+		} else if state.Type == "chatLine" {
+			fmt.Printf("[chat] %s: %s\n", state.Username, state.Text)
+		}
+
+	}
+}
+
+// printOffers surfaces the opponent's pending draw/takeback offers inline,
+// since the board API only reports them as flags on each gameState update.
+func printOffers(playingWithWhite, wdraw, bdraw, wtakeback, btakeback bool) {
+	opponentDraw, opponentTakeback := wdraw, wtakeback
+	if playingWithWhite {
+		opponentDraw, opponentTakeback = bdraw, btakeback
+	}
+
+	if opponentDraw {
+		fmt.Println("A draw has been offered, type `draw yes` to accept or `draw no` to decline")
+	}
+	if opponentTakeback {
+		fmt.Println("A takeback has been offered, type `takeback yes` to accept or `takeback no` to decline")
+	}
+}
+
+// playEngineMove asks engine for the best move in the current position and
+// plays it directly, for --auto mode. wtime/btime are the clocks from the
+// triggering gameState/gameFull event, used to render the board afterwards.
+func playEngineMove(s settings, localGame *chess.Game, engine *engineHolder, stateDir string, wtime int, btime int) {
+	uciMove, info, err := engine.bestMove(localGame.Position().String())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	san, err := uciMoveToSAN(localGame.Position(), uciMove)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Engine plays %s (%s)\n", san, info)
+
+	if err := localGame.MoveStr(san); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := sendMove(s, localGame.Position(), localGame.Moves()[len(localGame.Moves())-1]); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := savePGN(stateDir, s.GameId, localGame); err != nil {
+		log.Println("Couldn't persist the game PGN:", err)
+	}
+
+	fmt.Print(renderBoard(localGame, wtime, btime))
+}
+
+// takeTurn prompts for a single command or move and applies it, looping
+// until a move is made (exit/resign/abort end the process directly). wtime/
+// btime are the clocks from the triggering gameState/gameFull event, used to
+// render the board once the player's move is made.
+func takeTurn(s settings, localGame *chess.Game, completer prompt.Completer, stateDir string, pgnDir string, engine *engineHolder, wtime int, btime int) {
+	for {
+		t := prompt.Input("> ", completer)
+		fields := strings.Fields(t)
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "exit":
+			engine.Close()
+			os.Exit(0)
+		case "resign":
+			resign(s)
+			engine.Close()
+			os.Exit(0)
+		case "abort":
+			abort(s)
+			engine.Close()
+			os.Exit(0)
+		case "save":
+			if err := savePGN(pgnDir, s.GameId, localGame); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println("Saved to", pgnPath(pgnDir, s.GameId))
+			}
+			continue
+		case "draw":
+			if len(fields) != 2 {
+				fmt.Println("usage: draw yes|no")
+				continue
+			}
+			if err := draw(s, fields[1] == "yes"); err != nil {
+				fmt.Println(err)
+			}
+			continue
+		case "takeback":
+			if len(fields) != 2 {
+				fmt.Println("usage: takeback yes|no")
+				continue
+			}
+			if err := takeback(s, fields[1] == "yes"); err != nil {
+				fmt.Println(err)
+			}
+			continue
+		case "chat":
+			if len(fields) < 2 {
+				fmt.Println("usage: chat <message>")
+				continue
+			}
+			if err := chat(s, strings.Join(fields[1:], " ")); err != nil {
+				fmt.Println(err)
+			}
+			continue
+		case "hint":
+			uciMove, info, err := engine.bestMove(localGame.Position().String())
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			san, err := uciMoveToSAN(localGame.Position(), uciMove)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Printf("Hint: %s (%s)\n", san, info)
+			continue
+		case "eval":
+			_, info, err := engine.bestMove(localGame.Position().String())
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println(info)
+			continue
 		}
 
+		err := localGame.MoveStr(t)
+
+		if err != nil {
+			fmt.Println("Invalid move", err)
+		} else {
+			err := sendMove(s, localGame.Position(), localGame.Moves()[len(localGame.Moves())-1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := savePGN(stateDir, s.GameId, localGame); err != nil {
+				log.Println("Couldn't persist the game PGN:", err)
+			}
+			fmt.Print(renderBoard(localGame, wtime, btime))
+			return
+		}
 	}
 }
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/notnil/chess"
+)
+
+// gameSession tracks one of the user's concurrently active games.
+type gameSession struct {
+	GameId           string
+	LocalGame        *chess.Game
+	RemoteGame       *chess.Game
+	PlayingWithWhite bool
+	ReadyForInput    bool
+}
+
+// multiplexer fans out one streaming goroutine per active game and lets the
+// user switch the focused game from a single prompt, so several boards can
+// be played from one CLI invocation.
+type multiplexer struct {
+	s settings
+
+	mu       sync.Mutex
+	sessions map[string]*gameSession
+	focused  string
+}
+
+func newMultiplexer(s settings) *multiplexer {
+	return &multiplexer{
+		s:        s,
+		sessions: map[string]*gameSession{},
+	}
+}
+
+// playMulti listens on /api/stream/event for gameStart/gameFinish
+// notifications, opening and tearing down a per-game session as games come
+// and go, and drives a prompt that always acts on the focused session.
+func playMulti(s settings) {
+	m := newMultiplexer(s)
+
+	req, err := http.NewRequest("GET", "https://lichess.org/api/stream/event", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := doLichessRequest(s, req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		log.Fatal("Couldn't start listening for events")
+	}
+
+	go func() {
+		dec := json.NewDecoder(resp.Body)
+		for dec.More() {
+			var e lobbyEvent
+			if err := dec.Decode(&e); err != nil {
+				log.Println("event stream error:", err)
+				return
+			}
+
+			switch e.Type {
+			case "gameStart":
+				m.addSession(e.Game.Id)
+			case "gameFinish":
+				m.removeSession(e.Game.Id)
+			}
+		}
+	}()
+
+	m.repl()
+}
+
+// addSession opens a streaming goroutine for gameId and registers it as a
+// session, focusing it if it is the first (or only) active game.
+func (m *multiplexer) addSession(gameId string) {
+	session := &gameSession{
+		GameId:     gameId,
+		LocalGame:  chess.NewGame(chess.UseNotation(algebraicNotation)),
+		RemoteGame: chess.NewGame(chess.UseNotation(longAlgebraicNotation)),
+	}
+
+	m.mu.Lock()
+	m.sessions[gameId] = session
+	if m.focused == "" {
+		m.focused = gameId
+	}
+	m.mu.Unlock()
+
+	go m.streamSession(session)
+}
+
+func (m *multiplexer) removeSession(gameId string) {
+	m.mu.Lock()
+	delete(m.sessions, gameId)
+	if m.focused == gameId {
+		m.focused = ""
+		for id := range m.sessions {
+			m.focused = id
+			break
+		}
+	}
+	m.mu.Unlock()
+}
+
+// streamSession pumps gameFull/gameState events for a single game into its
+// session, independently of every other game's stream.
+func (m *multiplexer) streamSession(session *gameSession) {
+	gameSettings := m.s
+	gameSettings.GameId = session.GameId
+
+	resp, err := startStream(gameSettings)
+	if err != nil {
+		log.Println("couldn't stream game", session.GameId, err)
+		m.removeSession(session.GameId)
+		return
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var state gameState
+		if err := dec.Decode(&state); err != nil {
+			resp.Body.Close()
+
+			resp, err = reconnectStream(gameSettings)
+			if err != nil {
+				log.Println("giving up on game", session.GameId, err)
+				m.removeSession(session.GameId)
+				return
+			}
+			dec = json.NewDecoder(resp.Body)
+			continue
+		}
+
+		m.mu.Lock()
+		applyGameEvent(session, gameSettings.User, state)
+		m.mu.Unlock()
+	}
+}
+
+// applyGameEvent updates session in place from a gameFull/gameState event,
+// mirroring the single-game resync logic in playGame.
+func applyGameEvent(session *gameSession, user string, state gameState) {
+	if state.Type == "gameFull" {
+		session.RemoteGame = chess.NewGame(chess.UseNotation(longAlgebraicNotation))
+
+		moves := strings.Split(state.State.Moves, " ")
+		for _, m := range moves {
+			if err := session.RemoteGame.MoveStr(m); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+
+		session.LocalGame = chess.NewGame(chess.UseNotation(algebraicNotation))
+		for _, m := range session.RemoteGame.Moves() {
+			session.LocalGame.Move(m)
+		}
+
+		if state.White.Id == user {
+			session.PlayingWithWhite = true
+		} else if state.Black.Id == user {
+			session.PlayingWithWhite = false
+		}
+
+		isWhiteTurn := len(moves)%2 == 0
+		session.ReadyForInput = (session.PlayingWithWhite && isWhiteTurn) || (!session.PlayingWithWhite && !isWhiteTurn)
+	} else if state.Type == "gameState" {
+		moves := strings.Split(state.Moves, " ")
+		for i := len(session.RemoteGame.Moves()); i < len(moves); i++ {
+			m := moves[i]
+			if err := session.RemoteGame.MoveStr(m); err != nil {
+				log.Println(err)
+				return
+			}
+
+			if i >= len(session.LocalGame.Moves()) {
+				session.LocalGame.Move(session.RemoteGame.Moves()[i])
+			}
+		}
+
+		isWhiteTurn := len(moves)%2 == 0
+		session.ReadyForInput = (session.PlayingWithWhite && isWhiteTurn) || (!session.PlayingWithWhite && !isWhiteTurn)
+	}
+}
+
+// repl runs a single prompt on the main goroutine that always acts on the
+// currently focused game, plus a few commands for switching between tabs.
+func (m *multiplexer) repl() {
+	completer := func(d prompt.Document) []prompt.Suggest {
+		suggests := []prompt.Suggest{
+			{Text: "games", Description: "list active games"},
+			{Text: "switch", Description: "switch <gameid> focuses a different game"},
+			{Text: "resign", Description: "resign the focused game"},
+			{Text: "exit", Description: "exit the cli"},
+		}
+
+		if session := m.focusedSession(); session != nil {
+			m.mu.Lock()
+			validMoves := session.LocalGame.ValidMoves()
+			position := session.LocalGame.Position()
+			m.mu.Unlock()
+
+			for _, mv := range validMoves {
+				suggests = append(suggests, prompt.Suggest{Text: algebraicNotation.Encode(position, mv)})
+			}
+		}
+
+		return prompt.FilterHasPrefix(suggests, d.GetWordBeforeCursor(), true)
+	}
+
+	for {
+		t := prompt.Input(m.promptLabel(), completer)
+		fields := strings.Fields(t)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "exit":
+			os.Exit(0)
+		case "games":
+			m.listSessions()
+			continue
+		case "switch":
+			if len(fields) != 2 {
+				fmt.Println("usage: switch <gameid>")
+				continue
+			}
+			m.focus(fields[1])
+			continue
+		case "resign":
+			if session := m.focusedSession(); session != nil {
+				gameSettings := m.s
+				gameSettings.GameId = session.GameId
+				resign(gameSettings)
+			}
+			continue
+		}
+
+		session := m.focusedSession()
+		if session == nil {
+			fmt.Println("No focused game, use `games` to list active games")
+			continue
+		}
+
+		m.mu.Lock()
+		if !session.ReadyForInput {
+			m.mu.Unlock()
+			fmt.Println("Not your turn")
+			continue
+		}
+
+		if err := session.LocalGame.MoveStr(t); err != nil {
+			m.mu.Unlock()
+			fmt.Println("Invalid move", err)
+			continue
+		}
+
+		move := session.LocalGame.Moves()[len(session.LocalGame.Moves())-1]
+		position := session.LocalGame.Position()
+		m.mu.Unlock()
+
+		gameSettings := m.s
+		gameSettings.GameId = session.GameId
+		if err := sendMove(gameSettings, position, move); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (m *multiplexer) focusedSession() *gameSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sessions[m.focused]
+}
+
+func (m *multiplexer) focus(gameId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[gameId]; !ok {
+		fmt.Println("No active game with id", gameId)
+		return
+	}
+
+	m.focused = gameId
+}
+
+func (m *multiplexer) listSessions() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.sessions {
+		marker := " "
+		if id == m.focused {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, id)
+	}
+}
+
+func (m *multiplexer) promptLabel() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.focused == "" {
+		return "(no game)> "
+	}
+	return fmt.Sprintf("%s> ", m.focused)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const maxReconnectAttempts = 8
+
+// pgnPath returns the path used to persist/restore a game's PGN, keyed by
+// its lichess game id.
+func pgnPath(stateDir string, gameId string) string {
+	return filepath.Join(stateDir, gameId+".pgn")
+}
+
+// savePGN writes game's current PGN to disk so it can be restored with
+// --resume if the process is restarted mid-game.
+func savePGN(stateDir string, gameId string, game *chess.Game) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(pgnPath(stateDir, gameId), []byte(game.String()), 0644)
+}
+
+// loadPGN restores a game from the PGN persisted by a previous run of the
+// CLI, if any. It returns ok=false when there is nothing on disk to resume.
+func loadPGN(stateDir string, gameId string, notation chess.Notation) (game *chess.Game, ok bool, err error) {
+	data, err := ioutil.ReadFile(pgnPath(stateDir, gameId))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	pgn, err := chess.PGN(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return chess.NewGame(pgn, chess.UseNotation(notation)), true, nil
+}
+
+// archiveGame persists game's final PGN to pgnDir so finished games are kept
+// even after the state used for --resume is no longer needed.
+func archiveGame(pgnDir string, gameId string, game *chess.Game) {
+	if err := savePGN(pgnDir, gameId, game); err != nil {
+		log.Println("Couldn't archive the game PGN:", err)
+		return
+	}
+
+	log.Println("Archived finished game to", pgnPath(pgnDir, gameId))
+}
+
+// reconnectStream reopens the board game stream, backing off exponentially
+// with jitter between attempts so a flaky connection doesn't hammer the
+// lichess API.
+func reconnectStream(s settings) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxReconnectAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
+			jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
+			log.Printf("reconnecting to the game stream in %s (attempt %d/%d)", backoff+jitter, attempt+1, maxReconnectAttempts)
+			time.Sleep(backoff + jitter)
+		}
+
+		resp, err := startStream(s)
+		if err == nil {
+			return resp, nil
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
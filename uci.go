@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const minEngineInterval = 200 * time.Millisecond
+
+// uciInfo holds the last "info" line seen while waiting for bestmove, good
+// enough for a hint/eval command that just wants the top line.
+type uciInfo struct {
+	CentipawnScore int
+	MateIn         int
+	PV             string
+}
+
+// uciEngine wraps a UCI-speaking engine subprocess (Stockfish by default).
+// It is safe for concurrent use; calls are serialized and rate limited so a
+// fast --auto loop doesn't hammer the engine.
+type uciEngine struct {
+	path string
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Scanner
+	lastCall time.Time
+}
+
+// newUCIEngine spawns path, a UCI engine binary, and waits for it to
+// acknowledge the "uci"/"isready" handshake.
+func newUCIEngine(path string) (*uciEngine, error) {
+	e := &uciEngine{path: path}
+	if err := e.start(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *uciEngine) start() error {
+	cmd := exec.Command(e.path)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	e.cmd = cmd
+	e.stdin = stdin
+	e.stdout = bufio.NewScanner(stdout)
+
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+	if err := e.waitFor("uciok"); err != nil {
+		return err
+	}
+
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.waitFor("readyok")
+}
+
+// restart kills and respawns the engine process, used when writing a command
+// to it fails (for example because it has crashed). An engine that stays
+// alive but never answers is not detected.
+func (e *uciEngine) restart() error {
+	if e.cmd != nil && e.cmd.Process != nil {
+		e.cmd.Process.Kill()
+		e.cmd.Wait()
+	}
+	return e.start()
+}
+
+// BestMove asks the engine for its top move (and the "info" line describing
+// it) in the given position, thinking for movetime milliseconds.
+func (e *uciEngine) BestMove(fen string, movetimeMs int) (move string, info uciInfo, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if wait := minEngineInterval - time.Since(e.lastCall); wait > 0 {
+		time.Sleep(wait)
+	}
+	e.lastCall = time.Now()
+
+	if err := e.send(fmt.Sprintf("position fen %s", fen)); err != nil {
+		if err := e.restart(); err != nil {
+			return "", uciInfo{}, err
+		}
+		if err := e.send(fmt.Sprintf("position fen %s", fen)); err != nil {
+			return "", uciInfo{}, err
+		}
+	}
+
+	if err := e.send(fmt.Sprintf("go movetime %d", movetimeMs)); err != nil {
+		return "", uciInfo{}, err
+	}
+
+	for e.stdout.Scan() {
+		line := e.stdout.Text()
+
+		if strings.HasPrefix(line, "info") {
+			info = parseInfoLine(line)
+			continue
+		}
+
+		if strings.HasPrefix(line, "bestmove") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				return "", info, fmt.Errorf("malformed bestmove line: %q", line)
+			}
+			return fields[1], info, nil
+		}
+	}
+
+	if err := e.stdout.Err(); err != nil {
+		return "", info, err
+	}
+
+	return "", info, fmt.Errorf("engine closed its output before returning a bestmove")
+}
+
+func parseInfoLine(line string) uciInfo {
+	var info uciInfo
+
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		switch f {
+		case "cp":
+			if i+1 < len(fields) {
+				info.CentipawnScore, _ = strconv.Atoi(fields[i+1])
+			}
+		case "mate":
+			if i+1 < len(fields) {
+				info.MateIn, _ = strconv.Atoi(fields[i+1])
+			}
+		case "pv":
+			info.PV = strings.Join(fields[i+1:], " ")
+		}
+	}
+
+	return info
+}
+
+func (e *uciEngine) send(command string) error {
+	_, err := io.WriteString(e.stdin, command+"\n")
+	return err
+}
+
+func (e *uciEngine) waitFor(token string) error {
+	for e.stdout.Scan() {
+		if strings.TrimSpace(e.stdout.Text()) == token {
+			return nil
+		}
+	}
+	if err := e.stdout.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("engine closed before sending %q", token)
+}
+
+func (e *uciEngine) Close() {
+	if e.cmd != nil && e.cmd.Process != nil {
+		e.send("quit")
+		e.cmd.Process.Kill()
+	}
+}
+
+func (info uciInfo) String() string {
+	if info.MateIn != 0 {
+		return fmt.Sprintf("mate in %d (%s)", info.MateIn, info.PV)
+	}
+	return fmt.Sprintf("%+.2f (%s)", float64(info.CentipawnScore)/100, info.PV)
+}
+
+func logEngineCrash(path string, err error) {
+	log.Printf("engine %s crashed, restarting: %v", path, err)
+}
+
+// engineHolder lazily spawns the configured engine on first use, so a
+// session that never asks for a hint never pays the cost of starting it.
+type engineHolder struct {
+	path       string
+	movetimeMs int
+	engine     *uciEngine
+}
+
+// Close shuts down the underlying engine process, if one was ever spawned.
+func (h *engineHolder) Close() {
+	if h.engine != nil {
+		h.engine.Close()
+	}
+}
+
+func (h *engineHolder) get() (*uciEngine, error) {
+	if h.engine == nil {
+		e, err := newUCIEngine(h.path)
+		if err != nil {
+			return nil, err
+		}
+		h.engine = e
+	}
+	return h.engine, nil
+}
+
+// bestMove returns the engine's suggestion for fen, restarting the engine
+// once and retrying if it has crashed.
+func (h *engineHolder) bestMove(fen string) (string, uciInfo, error) {
+	e, err := h.get()
+	if err != nil {
+		return "", uciInfo{}, err
+	}
+
+	move, info, err := e.BestMove(fen, h.movetimeMs)
+	if err != nil {
+		logEngineCrash(h.path, err)
+		h.engine = nil
+
+		e, err := h.get()
+		if err != nil {
+			return "", uciInfo{}, err
+		}
+		return e.BestMove(fen, h.movetimeMs)
+	}
+
+	return move, info, nil
+}
+
+// uciMoveToSAN converts a UCI long-algebraic move (e.g. "e2e4") into the
+// algebraic notation used everywhere else in this package.
+func uciMoveToSAN(position *chess.Position, uciMove string) (string, error) {
+	move, err := longAlgebraicNotation.Decode(position, uciMove)
+	if err != nil {
+		return "", err
+	}
+
+	return algebraicNotation.Encode(position, move), nil
+}